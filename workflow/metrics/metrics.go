@@ -1,12 +1,20 @@
 package metrics
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	clientmetrics "k8s.io/client-go/tools/metrics"
 	"k8s.io/client-go/util/workqueue"
 
 	"github.com/argoproj/argo/pkg/apis/workflow/v1alpha1"
@@ -19,6 +27,9 @@ const (
 	DefaultMetricsServerPath = "/metrics"
 )
 
+// podLifecycleDurationBuckets covers a pod lifecycle from a second to ten minutes
+var podLifecycleDurationBuckets = []float64{1, 2, 5, 10, 30, 60, 120, 300, 600}
+
 type ServerConfig struct {
 	Enabled      bool
 	Path         string
@@ -45,27 +56,31 @@ type Metrics struct {
 	workflowsProcessed prometheus.Counter
 	workflowsByPhase   map[v1alpha1.NodePhase]prometheus.Gauge
 	workflows          map[string][]string
-	operationDurations prometheus.Histogram
+	operationMetrics   map[string]prometheus.Metric
 	errors             map[ErrorCause]prometheus.Counter
 	customMetrics      map[string]metric
 	workqueueMetrics   map[string]prometheus.Metric
+	restClientMetrics  map[string]prometheus.Metric
 
 	// Used to quickly check if a metric desc is already used by the system
 	defaultMetricDescs map[string]bool
 	metricNameHelps    map[string]string
 	logMetric          *prometheus.CounterVec
 
+	// registry backs the metrics server's promhttp handler; sub-collectors register onto it via
+	// RegisterCollector instead of registering onto the global default registerer
+	registry   *prometheus.Registry
+	collectors map[string]Collector
+
 	// Custom Wistia metrics
-	podDeletionLatency prometheus.Gauge
-	podGCAddedToQueue  prometheus.Counter
-	podGCRemovedFromQueue prometheus.Counter
-	podInformerAddPod prometheus.Counter
-	podInformerUpdatePod prometheus.Counter
-	podInformerDeletePod prometheus.Counter
+	podLifecycleMetrics  map[string]prometheus.Metric
+	podLifecycleObserved map[string]map[string]bool
+	// podGC is registered via RegisterCollector instead of being threaded through allMetrics(),
+	// the way an external subsystem (pod GC, cron, artifact GC, ...) would add its own metrics.
+	podGC                   *podGCMetrics
 	processNextItemDuration prometheus.Gauge
-	workflowQueueDepth prometheus.Gauge
-	podQueueDepth      prometheus.Gauge
-	deadlineExceeded   prometheus.Counter
+	workflowQueueDepth      prometheus.Gauge
+	podQueueDepth           prometheus.Gauge
 }
 
 func (m *Metrics) Levels() []log.Level {
@@ -80,36 +95,61 @@ func (m *Metrics) Fire(entry *log.Entry) error {
 var _ prometheus.Collector = &Metrics{}
 
 func New(metricsConfig, telemetryConfig ServerConfig) *Metrics {
+	registry := prometheus.NewRegistry()
+
 	metrics := &Metrics{
 		metricsConfig:      metricsConfig,
 		telemetryConfig:    telemetryConfig,
 		workflowsProcessed: newCounter("workflows_processed_count", "Number of workflow updates processed", nil),
 		workflowsByPhase:   getWorkflowPhaseGauges(),
 		workflows:          make(map[string][]string),
-		operationDurations: newHistogram("operation_duration_seconds", "Histogram of durations of operations", nil, []float64{0.1, 0.25, 0.5, 0.75, 1.0, 1.25, 1.5, 1.75, 2.0, 2.5, 3.0}),
+		operationMetrics:   make(map[string]prometheus.Metric),
 		errors:             getErrorCounters(),
 		customMetrics:      make(map[string]metric),
 		workqueueMetrics:   make(map[string]prometheus.Metric),
+		restClientMetrics:  make(map[string]prometheus.Metric),
+		podLifecycleMetrics: make(map[string]prometheus.Metric),
+		podLifecycleObserved: make(map[string]map[string]bool),
 		defaultMetricDescs: make(map[string]bool),
 		metricNameHelps:    make(map[string]string),
 		logMetric: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Name: "log_messages",
 			Help: "Total number of log messages.",
 		}, []string{"level"}),
-		podDeletionLatency: newGauge("wcustom_pod_deletion_latency", "Latency for pod deletion (ms)", nil),
-		podGCAddedToQueue: newCounter("wcustom_pod_gc_added_to_queue", "Pod GC requests added to queue", nil),
-		podGCRemovedFromQueue: newCounter("wcustom_pod_gc_removed_from_queue", "Pod GC requests removed from queue", nil),
-		podInformerAddPod: newCounter("wcustom_pod_informer_add_pod", "Pod informer notified that a pod was added", nil),
-		podInformerUpdatePod: newCounter("wcustom_pod_informer_update_pod", "Pod informer notified that a pod was updated", nil),
-		podInformerDeletePod: newCounter("wcustom_pod_informer_delete_pod", "Pod informer notified that a pod was deleted", nil),
+		registry:                registry,
+		collectors:              make(map[string]Collector),
 		processNextItemDuration: newGauge("wcustom_process_next_item_duration", "Latency for processNextItem (ms)", nil),
-		workflowQueueDepth: newGauge("wcustom_workflow_queue_depth", "Depth of workflow queue", nil),
-		podQueueDepth: newGauge("wcustom_pod_queue_depth", "Depth of pod queue", nil),
-		deadlineExceeded: newCounter("wcustom_deadline_exceeded", "Deadline exceeded", nil),
+		workflowQueueDepth:      newGauge("wcustom_workflow_queue_depth", "Depth of workflow queue", nil),
+		podQueueDepth:           newGauge("wcustom_pod_queue_depth", "Depth of pod queue", nil),
 	}
 
 	for _, metric := range metrics.allMetrics() {
-		metrics.defaultMetricDescs[metric.Desc().String()] = true
+		name, _ := recoverMetricNameAndHelpFromDesc(metric.Desc().String())
+		metrics.defaultMetricDescs[name] = true
+	}
+	// workqueueMetrics, restClientMetrics, podLifecycleMetrics, and operationMetrics are all
+	// lazily created and keyed by label combination on first observation (see e.g.
+	// NewWorkDurationMetric, Observe, observePodLifecycleDuration, OperationCompleted), so
+	// allMetrics() won't have an instance of most of these names at construction time. Register
+	// them explicitly so a colliding custom metric is rejected from the start rather than only
+	// once some queue/request/pod/operation happens to exercise that label combination first.
+	for _, name := range []string{
+		"operation_duration_seconds",
+		"queue_depth_count",
+		"queue_adds_count",
+		"queue_latency",
+		"workqueue_retries_total",
+		"workqueue_work_duration_seconds",
+		"workqueue_unfinished_work_seconds",
+		"workqueue_longest_running_processor_seconds",
+		"rest_client_request_latency_seconds",
+		"rest_client_requests_total",
+		"wf_pod_schedule_duration_seconds",
+		"wf_pod_start_duration_seconds",
+		"wf_pod_completion_duration_seconds",
+		"wf_pod_delete_duration_seconds",
+	} {
+		metrics.defaultMetricDescs[name] = true
 	}
 
 	for _, level := range metrics.Levels() {
@@ -118,64 +158,337 @@ func New(metricsConfig, telemetryConfig ServerConfig) *Metrics {
 
 	log.AddHook(metrics)
 
+	clientmetrics.Register(metrics, metrics)
+
+	metrics.registry.MustRegister(metrics)
+
+	// podGC demonstrates the Collector extension point end to end: it's built with plain
+	// prometheus constructors and handed to RegisterCollector instead of being threaded through
+	// allMetrics(), the way pod GC/cron/artifact GC would register their own metrics.
+	metrics.podGC = newPodGCMetrics()
+	if err := metrics.RegisterCollector(metrics.podGC); err != nil {
+		// New is only ever called once per controller process, so a name collision here is a
+		// programming error in this package, not a runtime condition callers need to handle.
+		panic(err)
+	}
+	for _, name := range []string{
+		"wcustom_pod_gc_added_to_queue",
+		"wcustom_pod_gc_removed_from_queue",
+		"wcustom_pod_informer_add_pod",
+		"wcustom_pod_informer_update_pod",
+		"wcustom_pod_informer_delete_pod",
+	} {
+		metrics.defaultMetricDescs[name] = true
+	}
+
 	return metrics
 }
 
+// Collector lets other controller subsystems (pod GC, cron, artifact GC, ...) contribute their
+// own metrics without editing this file, so long as they register before the metrics server starts.
+type Collector interface {
+	prometheus.Collector
+	// Name uniquely identifies the collector so it's only ever registered once.
+	Name() string
+}
+
+// RegisterCollector adds a sub-collector's metrics to the set served by Handler.
+func (m *Metrics) RegisterCollector(c Collector) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, exists := m.collectors[c.Name()]; exists {
+		return fmt.Errorf("collector '%s' is already registered", c.Name())
+	}
+	if err := m.registry.Register(c); err != nil {
+		return fmt.Errorf("failed to register collector '%s': %v", c.Name(), err)
+	}
+	m.collectors[c.Name()] = c
+	return nil
+}
+
+// Factory returns a promauto factory bound to this Metrics' private registry, so metrics built
+// with it can't accidentally end up registered against the global default registerer.
+func (m *Metrics) Factory() promauto.Factory {
+	return promauto.With(m.registry)
+}
+
+// Handler serves every metric registered on Metrics' private registry, including those
+// contributed by RegisterCollector.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// RunServer serves Handler on metricsConfig.Path/Port and, independently, on
+// telemetryConfig.Path/Port, until ctx is cancelled. Each is started purely off its own Enabled
+// flag, sharing a single mux/server when the two configs point at the same address (per
+// ServerConfig.SameServerAs). It's the metrics server the controller's main package should run
+// instead of exposing metrics on the default registerer/handler.
+func (m *Metrics) RunServer(ctx context.Context) error {
+	if !m.metricsConfig.Enabled && !m.telemetryConfig.Enabled {
+		return nil
+	}
+
+	var servers []*http.Server
+	errCh := make(chan error, 2)
+
+	if m.metricsConfig.Enabled {
+		mux := http.NewServeMux()
+		mux.Handle(m.metricsConfig.Path, m.Handler())
+		if m.telemetryConfig.Enabled && m.telemetryConfig.SameServerAs(m.metricsConfig) {
+			mux.Handle(m.telemetryConfig.Path, m.Handler())
+		}
+		srv := &http.Server{Addr: fmt.Sprintf(":%d", m.metricsConfig.Port), Handler: mux}
+		servers = append(servers, srv)
+		go func() { errCh <- srv.ListenAndServe() }()
+	}
+
+	if m.telemetryConfig.Enabled && !m.telemetryConfig.SameServerAs(m.metricsConfig) {
+		mux := http.NewServeMux()
+		mux.Handle(m.telemetryConfig.Path, m.Handler())
+		srv := &http.Server{Addr: fmt.Sprintf(":%d", m.telemetryConfig.Port), Handler: mux}
+		servers = append(servers, srv)
+		go func() { errCh <- srv.ListenAndServe() }()
+	}
+
+	select {
+	case <-ctx.Done():
+		var shutdownErr error
+		for _, srv := range servers {
+			if err := srv.Shutdown(context.Background()); err != nil {
+				shutdownErr = err
+			}
+		}
+		return shutdownErr
+	case err := <-errCh:
+		if err == http.ErrServerClosed {
+			return nil
+		}
+		return err
+	}
+}
+
+// podGCMetrics is a self-contained example of the Collector extension point above: it's built
+// with plain prometheus constructors (RegisterCollector does the registering, not promauto) and
+// wired in with RegisterCollector instead of being threaded through allMetrics(), the way a pod
+// GC/cron/artifact GC subsystem living in another package would do it.
+type podGCMetrics struct {
+	addedToQueue      prometheus.Counter
+	removedFromQueue  prometheus.Counter
+	informerAddPod    prometheus.Counter
+	informerUpdatePod prometheus.Counter
+	informerDeletePod prometheus.Counter
+}
+
+func newPodGCMetrics() *podGCMetrics {
+	return &podGCMetrics{
+		addedToQueue:      prometheus.NewCounter(prometheus.CounterOpts{Name: "wcustom_pod_gc_added_to_queue", Help: "Pod GC requests added to queue"}),
+		removedFromQueue:  prometheus.NewCounter(prometheus.CounterOpts{Name: "wcustom_pod_gc_removed_from_queue", Help: "Pod GC requests removed from queue"}),
+		informerAddPod:    prometheus.NewCounter(prometheus.CounterOpts{Name: "wcustom_pod_informer_add_pod", Help: "Pod informer notified that a pod was added"}),
+		informerUpdatePod: prometheus.NewCounter(prometheus.CounterOpts{Name: "wcustom_pod_informer_update_pod", Help: "Pod informer notified that a pod was updated"}),
+		informerDeletePod: prometheus.NewCounter(prometheus.CounterOpts{Name: "wcustom_pod_informer_delete_pod", Help: "Pod informer notified that a pod was deleted"}),
+	}
+}
+
+func (p *podGCMetrics) Name() string { return "pod-gc" }
+
+func (p *podGCMetrics) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(p, ch)
+}
+
+func (p *podGCMetrics) Collect(ch chan<- prometheus.Metric) {
+	ch <- p.addedToQueue
+	ch <- p.removedFromQueue
+	ch <- p.informerAddPod
+	ch <- p.informerUpdatePod
+	ch <- p.informerDeletePod
+}
+
+var _ Collector = &podGCMetrics{}
+
 func (m *Metrics) allMetrics() []prometheus.Metric {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
 
 	allMetrics := []prometheus.Metric{
 		m.workflowsProcessed,
-		m.operationDurations,
-		m.podDeletionLatency,
-		m.podGCAddedToQueue,
-		m.podGCRemovedFromQueue,
-		m.podInformerAddPod,
-		m.podInformerUpdatePod,
-		m.podInformerDeletePod,
 		m.processNextItemDuration,
 		m.workflowQueueDepth,
 		m.podQueueDepth,
-		m.deadlineExceeded,
 	}
 	for _, metric := range m.workflowsByPhase {
 		allMetrics = append(allMetrics, metric)
 	}
+	for _, metric := range m.operationMetrics {
+		allMetrics = append(allMetrics, metric)
+	}
 	for _, metric := range m.errors {
 		allMetrics = append(allMetrics, metric)
 	}
 	for _, metric := range m.workqueueMetrics {
 		allMetrics = append(allMetrics, metric)
 	}
+	for _, metric := range m.restClientMetrics {
+		allMetrics = append(allMetrics, metric)
+	}
+	for _, metric := range m.podLifecycleMetrics {
+		allMetrics = append(allMetrics, metric)
+	}
 	for _, metric := range m.customMetrics {
 		allMetrics = append(allMetrics, metric.metric)
 	}
 	return allMetrics
 }
 
-func (m *Metrics) UpdatePodDeletionLatency(latencyMs int64) {
-	m.podDeletionLatency.Set(float64(latencyMs))
-}
-
 func (m *Metrics) IncrementPodGCAddedToQueue() {
-	m.podGCAddedToQueue.Inc()
+	m.podGC.addedToQueue.Inc()
 }
 
 func (m *Metrics) IncrementPodGCRemovedFromQueue() {
-	m.podGCRemovedFromQueue.Inc()
+	m.podGC.removedFromQueue.Inc()
+}
+
+func (m *Metrics) IncrementPodInformerAddPod(pod *corev1.Pod) {
+	m.podGC.informerAddPod.Inc()
+	m.observePodScheduleDuration(pod)
+	m.observePodStartDuration(pod)
+}
+
+func (m *Metrics) IncrementPodInformerUpdatePod(pod *corev1.Pod) {
+	m.podGC.informerUpdatePod.Inc()
+	m.observePodScheduleDuration(pod)
+	m.observePodStartDuration(pod)
+	m.observePodCompletionDuration(pod)
+}
+
+func (m *Metrics) IncrementPodInformerDeletePod(pod *corev1.Pod) {
+	m.podGC.informerDeletePod.Inc()
+	m.observePodDeleteDuration(pod)
+}
+
+// observePodLifecycleDuration records a pod lifecycle phase transition into a histogram that's
+// lazily created and keyed by metric+namespace+phase, same as the workqueue/client metrics below.
+func (m *Metrics) observePodLifecycleDuration(phaseName, metricName, help string, pod *corev1.Pod, duration time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	key := fmt.Sprintf("%s-%s-%s", phaseName, pod.Namespace, pod.Status.Phase)
+	if _, ok := m.podLifecycleMetrics[key]; !ok {
+		m.podLifecycleMetrics[key] = newHistogram(metricName, help, map[string]string{"namespace": pod.Namespace, "phase": string(pod.Status.Phase)}, podLifecycleDurationBuckets)
+	}
+	m.podLifecycleMetrics[key].(prometheus.Histogram).Observe(duration.Seconds())
+}
+
+// markPodLifecyclePhaseObserved records that a pod lifecycle transition has been observed for a
+// given pod, returning false if it was already recorded. A SharedInformer redelivers UpdateFunc
+// for every cached object on each resync even when nothing changed, so without this a pod's
+// schedule/start/completion duration would be re-observed into the histogram on every resync.
+func (m *Metrics) markPodLifecyclePhaseObserved(pod *corev1.Pod, phaseName string) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	uid := string(pod.UID)
+	observed, ok := m.podLifecycleObserved[uid]
+	if !ok {
+		observed = make(map[string]bool)
+		m.podLifecycleObserved[uid] = observed
+	}
+	if observed[phaseName] {
+		return false
+	}
+	observed[phaseName] = true
+	return true
+}
+
+// clearPodLifecycleObserved drops a pod's dedup bookkeeping once it's been deleted, so
+// podLifecycleObserved doesn't grow forever as pods churn.
+func (m *Metrics) clearPodLifecycleObserved(pod *corev1.Pod) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.podLifecycleObserved, string(pod.UID))
+}
+
+func (m *Metrics) observePodScheduleDuration(pod *corev1.Pod) {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodScheduled && cond.Status == corev1.ConditionTrue {
+			if m.markPodLifecyclePhaseObserved(pod, "schedule") {
+				m.observePodLifecycleDuration("schedule", "wf_pod_schedule_duration_seconds", "Duration between a pod being created and scheduled", pod, cond.LastTransitionTime.Sub(pod.CreationTimestamp.Time))
+			}
+			return
+		}
+	}
 }
 
-func (m *Metrics) IncrementPodInformerAddPod() {
-	m.podInformerAddPod.Inc()
+func (m *Metrics) observePodStartDuration(pod *corev1.Pod) {
+	startedAt, ok := earliestContainerStartTime(pod)
+	if !ok {
+		return
+	}
+	if m.markPodLifecyclePhaseObserved(pod, "start") {
+		m.observePodLifecycleDuration("start", "wf_pod_start_duration_seconds", "Duration between a pod being created and its first container running", pod, startedAt.Sub(pod.CreationTimestamp.Time))
+	}
+}
+
+// earliestContainerStartTime returns the earliest StartedAt among the pod's running containers.
+// ContainerStatuses isn't ordered by actual start time, so taking the first running entry can
+// pick a later-started sidecar/init container ahead of the one that actually started first.
+func earliestContainerStartTime(pod *corev1.Pod) (time.Time, bool) {
+	var earliest time.Time
+	found := false
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.State.Running == nil {
+			continue
+		}
+		if t := cs.State.Running.StartedAt.Time; !found || t.Before(earliest) {
+			earliest = t
+			found = true
+		}
+	}
+	return earliest, found
+}
+
+func (m *Metrics) observePodCompletionDuration(pod *corev1.Pod) {
+	if pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed {
+		return
+	}
+	finishedAt, ok := latestContainerFinishTime(pod)
+	if !ok {
+		return
+	}
+	if m.markPodLifecyclePhaseObserved(pod, "completion") {
+		m.observePodLifecycleDuration("completion", "wf_pod_completion_duration_seconds", "Duration between a pod being created and reaching a terminal phase", pod, finishedAt.Sub(pod.CreationTimestamp.Time))
+	}
 }
 
-func (m *Metrics) IncrementPodInformerUpdatePod() {
-	m.podInformerUpdatePod.Inc()
+// latestContainerFinishTime returns the latest container termination timestamp reported by the
+// pod's status, i.e. when the pod itself actually finished running. Using time.Now() here would
+// measure how long our own workqueue backlog delayed processing the Update event rather than the
+// pod's actual completion duration.
+func latestContainerFinishTime(pod *corev1.Pod) (time.Time, bool) {
+	var latest time.Time
+	found := false
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.State.Terminated == nil {
+			continue
+		}
+		if t := cs.State.Terminated.FinishedAt.Time; !found || t.After(latest) {
+			latest = t
+			found = true
+		}
+	}
+	return latest, found
 }
 
-func (m *Metrics) IncrementPodInformerDeletePod() {
-	m.podInformerDeletePod.Inc()
+func (m *Metrics) observePodDeleteDuration(pod *corev1.Pod) {
+	defer m.clearPodLifecycleObserved(pod)
+
+	if pod.DeletionTimestamp == nil {
+		return
+	}
+	if m.markPodLifecyclePhaseObserved(pod, "delete") {
+		m.observePodLifecycleDuration("delete", "wf_pod_delete_duration_seconds", "Duration between a pod's deletion being requested and the informer observing its removal", pod, time.Since(pod.DeletionTimestamp.Time))
+	}
 }
 
 func (m *Metrics) UpdateProcessNextItemDuration(latencyMs int64) {
@@ -190,10 +503,6 @@ func (m *Metrics) UpdatePodQueueDepth(depth int) {
 	m.podQueueDepth.Set(float64(depth))
 }
 
-func (m *Metrics) IncrementDeadlineExceeded() {
-	m.deadlineExceeded.Inc()
-}
-
 func (m *Metrics) StopRealtimeMetricsForKey(key string) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
@@ -210,11 +519,30 @@ func (m *Metrics) StopRealtimeMetricsForKey(key string) {
 	delete(m.workflows, key)
 }
 
-func (m *Metrics) OperationCompleted(durationSeconds float64) {
+// OperationOutcome categorizes how a workflow reconcile operation ended, used as the
+// "outcome" label on operation_duration_seconds.
+type OperationOutcome string
+
+const (
+	OperationOutcomeSuccess          OperationOutcome = "success"
+	OperationOutcomePanic            OperationOutcome = "panic"
+	OperationOutcomeRequeue          OperationOutcome = "requeue"
+	OperationOutcomeDeadlineExceeded OperationOutcome = "deadline_exceeded"
+)
+
+// workflowTemplateLabelKey is the label argo stamps onto workflows instantiated from a WorkflowTemplate
+const workflowTemplateLabelKey = "workflows.argoproj.io/workflow-template"
+
+func (m *Metrics) OperationCompleted(wf *v1alpha1.Workflow, outcome OperationOutcome, duration time.Duration) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	m.operationDurations.Observe(durationSeconds)
+	template := wf.Labels[workflowTemplateLabelKey]
+	key := fmt.Sprintf("%s-%s-%s", template, wf.Namespace, outcome)
+	if _, ok := m.operationMetrics[key]; !ok {
+		m.operationMetrics[key] = newHistogram("operation_duration_seconds", "Histogram of durations of operations, labeled by workflow template, namespace and outcome", map[string]string{"workflow_template": template, "namespace": wf.Namespace, "outcome": string(outcome)}, []float64{0.1, 0.25, 0.5, 0.75, 1.0, 1.25, 1.5, 1.75, 2.0, 2.5, 3.0})
+	}
+	m.operationMetrics[key].(prometheus.Histogram).Observe(duration.Seconds())
 }
 
 func (m *Metrics) GetCustomMetric(key string) prometheus.Metric {
@@ -230,10 +558,10 @@ func (m *Metrics) UpsertCustomMetric(key string, ownerKey string, newMetric prom
 	defer m.mutex.Unlock()
 
 	metricDesc := newMetric.Desc().String()
-	if _, inUse := m.defaultMetricDescs[metricDesc]; inUse {
+	name, help := recoverMetricNameAndHelpFromDesc(metricDesc)
+	if _, inUse := m.defaultMetricDescs[name]; inUse {
 		return fmt.Errorf("metric '%s' is already in use by the system, please use a different name", newMetric.Desc())
 	}
-	name, help := recoverMetricNameAndHelpFromDesc(metricDesc)
 	if existingHelp, inUse := m.metricNameHelps[name]; inUse && help != existingHelp {
 		return fmt.Errorf("metric '%s' has help string '%s' but should have '%s' (help strings must be identical for metrics of the same name)", name, help, existingHelp)
 	} else {
@@ -259,15 +587,15 @@ func (m *Metrics) SetWorkflowPhaseGauge(phase v1alpha1.NodePhase, num int) {
 type ErrorCause string
 
 const (
-	ErrorCauseOperationPanic              ErrorCause = "OperationPanic"
 	ErrorCauseCronWorkflowSubmissionError ErrorCause = "CronWorkflowSubmissionError"
 )
 
-func (m *Metrics) OperationPanic() {
-	m.mutex.Lock()
-	defer m.mutex.Unlock()
+func (m *Metrics) OperationPanic(wf *v1alpha1.Workflow, duration time.Duration) {
+	m.OperationCompleted(wf, OperationOutcomePanic, duration)
+}
 
-	m.errors[ErrorCauseOperationPanic].Inc()
+func (m *Metrics) IncrementDeadlineExceeded(wf *v1alpha1.Workflow, duration time.Duration) {
+	m.OperationCompleted(wf, OperationOutcomeDeadlineExceeded, duration)
 }
 
 func (m *Metrics) CronWorkflowSubmissionError() {
@@ -313,19 +641,106 @@ func (m *Metrics) NewLatencyMetric(name string) workqueue.HistogramMetric {
 	return m.workqueueMetrics[key].(prometheus.Histogram)
 }
 
-// These metrics are not relevant to be exposed
-type noopMetric struct{}
+// Act as a metrics provider for the client-go REST client
+var _ clientmetrics.LatencyMetric = &Metrics{}
+var _ clientmetrics.ResultMetric = &Metrics{}
+
+// sanitizeRequestPath collapses a Kubernetes REST request path down to its resource shape,
+// dropping the query string and replacing namespace/object names with placeholders so a
+// client hitting thousands of distinct pods/workflows doesn't create a metric series per object.
+//
+// Recognized shapes (group is absent for the core "api" group):
+//
+//	/api/{version}/namespaces/{namespace}/{resource}/{name}/{subresource}
+//	/apis/{group}/{version}/namespaces/{namespace}/{resource}/{name}/{subresource}
+func sanitizeRequestPath(p string) string {
+	segments := strings.Split(strings.Trim(p, "/"), "/")
+	if len(segments) == 0 {
+		return "/"
+	}
+
+	i := 0
+	switch segments[i] {
+	case "apis":
+		i += 3 // apis, group, version
+	case "api":
+		i += 2 // api, version
+	}
+	if i+1 < len(segments) && segments[i] == "namespaces" {
+		segments[i+1] = "{namespace}"
+		i += 2
+	}
+	i++ // resource type
+	if i < len(segments) {
+		segments[i] = "{name}"
+	}
+
+	return "/" + strings.Join(segments, "/")
+}
+
+func (m *Metrics) Observe(verb string, u url.URL, latency time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	path := sanitizeRequestPath(u.Path)
+	key := fmt.Sprintf("rest-client-latency-%s-%s", verb, path)
+	if _, ok := m.restClientMetrics[key]; !ok {
+		m.restClientMetrics[key] = newHistogram("rest_client_request_latency_seconds", "Request latency in seconds, partitioned by verb and URL", map[string]string{"verb": verb, "url": path}, []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1.0, 2.5, 5.0, 10.0})
+	}
+	m.restClientMetrics[key].(prometheus.Histogram).Observe(latency.Seconds())
+}
+
+func (m *Metrics) Increment(code, method, host string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	key := fmt.Sprintf("rest-client-result-%s-%s-%s", code, method, host)
+	if _, ok := m.restClientMetrics[key]; !ok {
+		m.restClientMetrics[key] = newCounter("rest_client_requests_total", "Number of HTTP requests, partitioned by status code, method, and host", map[string]string{"code": code, "method": method, "host": host})
+	}
+	m.restClientMetrics[key].(prometheus.Counter).Inc()
+}
 
-func (noopMetric) Inc()            {}
-func (noopMetric) Dec()            {}
-func (noopMetric) Set(float64)     {}
-func (noopMetric) Observe(float64) {}
+func (m *Metrics) NewRetriesMetric(name string) workqueue.CounterMetric {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	key := fmt.Sprintf("%s-retries", name)
+	if _, ok := m.workqueueMetrics[key]; !ok {
+		m.workqueueMetrics[key] = newCounter("workqueue_retries_total", "Total number of retries handled by workqueue", map[string]string{"queue_name": name})
+	}
+	return m.workqueueMetrics[key].(prometheus.Counter)
+}
+
+func (m *Metrics) NewWorkDurationMetric(name string) workqueue.HistogramMetric {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	key := fmt.Sprintf("%s-work-duration", name)
+	if _, ok := m.workqueueMetrics[key]; !ok {
+		m.workqueueMetrics[key] = newHistogram("workqueue_work_duration_seconds", "How long in seconds processing an item from workqueue takes", map[string]string{"queue_name": name}, prometheus.ExponentialBuckets(1e-8, 2, 31))
+	}
+	return m.workqueueMetrics[key].(prometheus.Histogram)
+}
 
-func (m *Metrics) NewRetriesMetric(name string) workqueue.CounterMetric        { return noopMetric{} }
-func (m *Metrics) NewWorkDurationMetric(name string) workqueue.HistogramMetric { return noopMetric{} }
 func (m *Metrics) NewUnfinishedWorkSecondsMetric(name string) workqueue.SettableGaugeMetric {
-	return noopMetric{}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	key := fmt.Sprintf("%s-unfinished-work-seconds", name)
+	if _, ok := m.workqueueMetrics[key]; !ok {
+		m.workqueueMetrics[key] = newGauge("workqueue_unfinished_work_seconds", "How many seconds of work has been done that is in progress and hasn't been observed by work_duration", map[string]string{"queue_name": name})
+	}
+	return m.workqueueMetrics[key].(prometheus.Gauge)
 }
+
 func (m *Metrics) NewLongestRunningProcessorSecondsMetric(name string) workqueue.SettableGaugeMetric {
-	return noopMetric{}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	key := fmt.Sprintf("%s-longest-running-processor-seconds", name)
+	if _, ok := m.workqueueMetrics[key]; !ok {
+		m.workqueueMetrics[key] = newGauge("workqueue_longest_running_processor_seconds", "How many seconds has the longest running processor for workqueue been running", map[string]string{"queue_name": name})
+	}
+	return m.workqueueMetrics[key].(prometheus.Gauge)
 }
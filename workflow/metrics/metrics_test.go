@@ -0,0 +1,121 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSanitizeRequestPath(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{
+			name: "core group, namespaced, get",
+			path: "/api/v1/namespaces/default/pods/my-pod",
+			want: "/api/v1/namespaces/{namespace}/pods/{name}",
+		},
+		{
+			name: "core group, namespaced, list",
+			path: "/api/v1/namespaces/default/pods",
+			want: "/api/v1/namespaces/{namespace}/pods",
+		},
+		{
+			name: "apis group, namespaced, get",
+			path: "/apis/batch/v1/namespaces/default/jobs/my-job",
+			want: "/apis/batch/v1/namespaces/{namespace}/jobs/{name}",
+		},
+		{
+			name: "core group, cluster-scoped, get",
+			path: "/api/v1/nodes/my-node",
+			want: "/api/v1/nodes/{name}",
+		},
+		{
+			name: "apis group, cluster-scoped, get",
+			path: "/apis/apiextensions.k8s.io/v1/customresourcedefinitions/my-crd",
+			want: "/apis/apiextensions.k8s.io/v1/customresourcedefinitions/{name}",
+		},
+		{
+			name: "core group, namespaced, subresource",
+			path: "/api/v1/namespaces/default/pods/my-pod/status",
+			want: "/api/v1/namespaces/{namespace}/pods/{name}/status",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeRequestPath(tt.path); got != tt.want {
+				t.Errorf("sanitizeRequestPath(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarkPodLifecyclePhaseObserved(t *testing.T) {
+	m := &Metrics{podLifecycleObserved: make(map[string]map[string]bool)}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{UID: "uid-1"}}
+
+	if !m.markPodLifecyclePhaseObserved(pod, "schedule") {
+		t.Fatal("first observation of a phase should return true")
+	}
+	if m.markPodLifecyclePhaseObserved(pod, "schedule") {
+		t.Fatal("re-observing the same phase for the same pod should return false")
+	}
+	if !m.markPodLifecyclePhaseObserved(pod, "start") {
+		t.Fatal("a different phase for the same pod should be independently observable")
+	}
+
+	m.clearPodLifecycleObserved(pod)
+	if _, ok := m.podLifecycleObserved[string(pod.UID)]; ok {
+		t.Fatal("clearPodLifecycleObserved should remove the pod's bookkeeping entirely")
+	}
+	if !m.markPodLifecyclePhaseObserved(pod, "schedule") {
+		t.Fatal("a phase should be observable again after clearPodLifecycleObserved")
+	}
+}
+
+// TestObservePodScheduleDuration_DedupesResync guards against the SharedInformer resync replay
+// bug fixed in 5e65e98: UpdateFunc fires again for every cached pod on each resync even though
+// nothing changed, and that must not re-observe the histogram.
+func TestObservePodScheduleDuration_DedupesResync(t *testing.T) {
+	m := New(ServerConfig{}, ServerConfig{})
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			UID:               "uid-1",
+			Namespace:         "default",
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Minute)),
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			Conditions: []corev1.PodCondition{
+				{
+					Type:               corev1.PodScheduled,
+					Status:             corev1.ConditionTrue,
+					LastTransitionTime: metav1.NewTime(time.Now().Add(-50 * time.Second)),
+				},
+			},
+		},
+	}
+
+	m.observePodScheduleDuration(pod)
+	m.observePodScheduleDuration(pod) // simulate the informer redelivering Update on resync
+
+	key := "schedule-" + pod.Namespace + "-" + string(pod.Status.Phase)
+	hist, ok := m.podLifecycleMetrics[key]
+	if !ok {
+		t.Fatal("expected a schedule duration histogram to have been created")
+	}
+
+	var out dto.Metric
+	if err := hist.Write(&out); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	if got := out.GetHistogram().GetSampleCount(); got != 1 {
+		t.Errorf("sample count after a resync replay = %d, want 1", got)
+	}
+}